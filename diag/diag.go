@@ -0,0 +1,78 @@
+// Package diag provides a shared diagnostics type for the parser, sema and
+// irgen passes, so failures are collected and reported in the style of
+// gc/go's own compiler errors instead of aborting the process.
+package diag
+
+import (
+	"fmt"
+
+	"github.com/bongo227/Furlang/lexer"
+)
+
+// Severity classifies how serious a Diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return "unknown severity"
+	}
+}
+
+// Diagnostic is a single problem found while compiling, positioned at the
+// source location that caused it.
+type Diagnostic struct {
+	Pos      lexer.Position
+	Severity Severity
+	Code     string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", d.Pos, d.Severity, d.Message, d.Code)
+}
+
+// Reporter collects diagnostics as they're found instead of failing fast,
+// so a caller can see every problem a pass ran into in one go.
+type Reporter struct {
+	diagnostics []Diagnostic
+}
+
+// NewReporter returns an empty Reporter.
+func NewReporter() *Reporter {
+	return &Reporter{}
+}
+
+// Errorf records an error-severity diagnostic at pos.
+func (r *Reporter) Errorf(pos lexer.Position, code, format string, args ...interface{}) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{
+		Pos:      pos,
+		Severity: Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Diagnostics returns every diagnostic recorded so far.
+func (r *Reporter) Diagnostics() []Diagnostic {
+	return r.diagnostics
+}
+
+// HasErrors reports whether any recorded diagnostic is error-severity.
+func (r *Reporter) HasErrors() bool {
+	for _, d := range r.diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
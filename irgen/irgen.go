@@ -2,61 +2,177 @@ package irgen
 
 import (
 	"fmt"
+	"reflect"
 	"strconv"
 
 	"github.com/bongo227/Furlang/ast"
+	"github.com/bongo227/Furlang/diag"
 	"github.com/bongo227/Furlang/lexer"
+	"github.com/bongo227/Furlang/sema"
 	"github.com/bongo227/Furlang/types"
 	"github.com/bongo227/goory"
 
-	"log"
-
-	"reflect"
-
 	gooryvalues "github.com/bongo227/goory/value"
-	"github.com/k0kubun/pp"
 )
 
 type Irgen struct {
 	tree        *ast.Ast
+	info        *sema.Info
+	diags       *diag.Reporter
 	module      *goory.Module
 	parentBlock *goory.Block
 	scope       *Scope
+	loopStack   []loopContext
+}
+
+// errorf records an error diagnostic at pos rather than aborting, so
+// codegen can keep going and report every problem it finds in one pass.
+func (g *Irgen) errorf(pos lexer.Position, code, format string, args ...interface{}) {
+	g.diags.Errorf(pos, code, format, args...)
+}
+
+// poison stands in for a value Irgen couldn't construct because of an error
+// it already reported through errorf.
+func (g *Irgen) poison() gooryvalues.Value {
+	return goory.Constant(goory.IntType(64), 0)
+}
+
+// loopContext tracks the blocks of an enclosing for loop so that break and
+// continue statements know where to branch to.
+type loopContext struct {
+	header *goory.Block
+	latch  *goory.Block
+	exit   *goory.Block
+}
+
+// structType records everything Irgen needs to lower a named struct: its
+// LLVM representation and the order its fields were declared in, so a
+// selector or composite literal can turn a field name into a GEP index.
+type structType struct {
+	llvm   goory.Type
+	fields []string
+}
+
+// structTypeName returns the name of t if it refers to a named struct type,
+// so the scope can later resolve field selectors and method calls against
+// it.
+func structTypeName(t types.Type) (string, bool) {
+	named, ok := t.(interface{ Name() string })
+	if !ok {
+		return "", false
+	}
+	return named.Name(), true
 }
 
-func NewIrgen(tree *ast.Ast) *Irgen {
-	return &Irgen{
+// Field indices of the { ptr, len, cap } struct a slice value lowers to.
+const (
+	sliceDataField = 0
+	sliceLenField  = 1
+	sliceCapField  = 2
+)
+
+// NewIrgen builds an Irgen ready to lower tree. info is the result of
+// running sema.Check over the same tree; Irgen trusts it rather than
+// re-deriving types or identifier bindings itself. diags collects any
+// diagnostics codegen runs into, and may be shared with the parser and
+// sema passes so all of them report through the one Reporter.
+func NewIrgen(tree *ast.Ast, info *sema.Info, diags *diag.Reporter) *Irgen {
+	module := goory.NewModule("test")
+
+	g := &Irgen{
 		tree:   tree,
-		module: goory.NewModule("test"),
+		info:   info,
+		diags:  diags,
+		module: module,
 		scope:  NewScope(),
 	}
+
+	// Runtime declarations codegen calls into but never generates itself.
+	g.scope.AddFunction("panic_index_out_of_range",
+		module.NewFunction("panic_index_out_of_range", goory.VoidType()))
+	g.scope.AddFunction("runtime_alloc",
+		module.NewFunction("runtime_alloc", goory.PointerType(goory.IntType(8))))
+
+	return g
 }
 
-func (g *Irgen) Generate() string {
+// Generate lowers the whole tree to LLVM IR. It never aborts partway
+// through; if codegen ran into any errors, they're returned alongside a
+// non-nil error and an empty module text.
+func (g *Irgen) Generate() (string, []diag.Diagnostic, error) {
+	for _, s := range g.tree.Structs {
+		g.structDecl(s)
+	}
+
 	for _, f := range g.tree.Functions {
 		g.function(f)
 	}
 
-	return g.module.LLVM()
+	diagnostics := g.diags.Diagnostics()
+	if g.diags.HasErrors() {
+		return "", diagnostics, fmt.Errorf("irgen: %d error(s)", len(diagnostics))
+	}
+
+	return g.module.LLVM(), diagnostics, nil
+}
+
+// structDecl registers a named LLVM struct type for node, keyed by name in
+// the scope's type table so composite literals and field selectors can look
+// it up.
+func (g *Irgen) structDecl(node *ast.StructDeclaration) {
+	name := node.Name.Value.Value()
+
+	fieldTypes := make([]goory.Type, len(node.Fields))
+	fieldNames := make([]string, len(node.Fields))
+	for i, field := range node.Fields {
+		fieldTypes[i] = field.Type.Llvm()
+		fieldNames[i] = field.Name.Value.Value()
+	}
+
+	llvm := g.module.NewStructType(name, fieldTypes...)
+	g.scope.AddType(name, &structType{llvm: llvm, fields: fieldNames})
 }
 
 func (g *Irgen) function(node *ast.FunctionDeclaration) {
 	// Create new function in module
 	fName := node.Name.Value.Value()
+	args := node.Arguments
+
+	// Desugar a method into a function taking the receiver as its first
+	// argument, registered against the receiver's struct type rather than
+	// by name so `x.method(...)` calls can find it.
+	var receiverType string
+	if node.Receiver != nil {
+		var ok bool
+		receiverType, ok = structTypeName(node.Receiver.Type)
+		if !ok {
+			g.errorf(node.Receiver.Pos(), "bad-receiver", "receiver of %q has no named struct type", fName)
+		} else {
+			args = append([]*ast.Argument{node.Receiver}, args...)
+		}
+	}
+
 	f := g.module.NewFunction(fName, node.Return.Llvm())
 
-	g.scope.AddFunction(fName, f)
+	if node.Receiver != nil {
+		g.scope.AddMethod(receiverType, fName, f)
+	} else {
+		g.scope.AddFunction(fName, f)
+	}
 	g.parentBlock = f.Entry()
 
 	// Add arguments to function
-	for _, arg := range node.Arguments {
+	for _, arg := range args {
 		name := arg.Name.Value.Value()
 		argType := arg.Type.Llvm()
-		arg := f.AddArgument(argType, name)
+		gArg := f.AddArgument(argType, name)
 
 		alloc := g.parentBlock.Alloca(argType)
-		g.parentBlock.Store(alloc, arg)
+		g.parentBlock.Store(alloc, gArg)
 		g.scope.AddVar(name, alloc)
+		if structName, ok := structTypeName(arg.Type); ok {
+			g.scope.AddVarType(name, structName)
+		}
 	}
 
 	g.block(node.Body)
@@ -72,8 +188,6 @@ func (g *Irgen) block(node *ast.BlockStatement) {
 }
 
 func (g *Irgen) statement(node ast.Statement) {
-	log.Printf("Statement of type %q", reflect.TypeOf(node).String())
-
 	switch node := node.(type) {
 	case *ast.IfStatment:
 		endBlock := g.parentBlock.Function().AddBlock()
@@ -84,6 +198,12 @@ func (g *Irgen) statement(node ast.Statement) {
 		g.declareSmt(node)
 	case *ast.AssignmentStatement:
 		g.assignmentSmt(node)
+	case *ast.ForStatement:
+		g.forSmt(node)
+	case *ast.BreakStatement:
+		g.breakSmt(node)
+	case *ast.ContinueStatement:
+		g.continueSmt(node)
 	}
 
 }
@@ -126,6 +246,80 @@ func (g *Irgen) ifSmt(node *ast.IfStatment, block, endBlock *goory.Block) {
 	g.parentBlock = endBlock
 }
 
+// forSmt lowers a for statement into a pre-header (running the init), a
+// header block that evaluates the condition, a body block, and a latch
+// block that runs the post statement before branching back to the header.
+func (g *Irgen) forSmt(node *ast.ForStatement) {
+	parent := g.parentBlock
+	parentScope := g.scope
+	g.scope = g.scope.Push()
+
+	if node.Init != nil {
+		g.statement(node.Init)
+	}
+
+	function := parent.Function()
+	header := function.AddBlock()
+	body := function.AddBlock()
+	latch := function.AddBlock()
+	exit := function.AddBlock()
+
+	if !g.parentBlock.Terminated() {
+		g.parentBlock.Br(header)
+	}
+
+	g.loopStack = append(g.loopStack, loopContext{header: header, latch: latch, exit: exit})
+
+	g.parentBlock = header
+	if node.Condition != nil {
+		condition := g.expression(node.Condition)
+		g.parentBlock.CondBr(condition, body, exit)
+	} else {
+		header.Br(body)
+	}
+
+	g.parentBlock = body
+	g.block(node.Body)
+	if !g.parentBlock.Terminated() {
+		g.parentBlock.Br(latch)
+	}
+
+	g.parentBlock = latch
+	if node.Post != nil {
+		g.statement(node.Post)
+	}
+	if !g.parentBlock.Terminated() {
+		g.parentBlock.Br(header)
+	}
+
+	g.loopStack = g.loopStack[:len(g.loopStack)-1]
+	g.parentBlock = exit
+	g.scope = parentScope
+}
+
+// breakSmt branches to the exit block of the innermost enclosing loop, or
+// reports a diagnostic if break appears outside of one.
+func (g *Irgen) breakSmt(node *ast.BreakStatement) {
+	if len(g.loopStack) == 0 {
+		g.errorf(node.Pos(), "break-outside-loop", "break outside of loop")
+		return
+	}
+	loop := g.loopStack[len(g.loopStack)-1]
+	g.parentBlock.Br(loop.exit)
+}
+
+// continueSmt branches to the latch block of the innermost enclosing loop,
+// running its post statement before re-checking the condition, or reports a
+// diagnostic if continue appears outside of one.
+func (g *Irgen) continueSmt(node *ast.ContinueStatement) {
+	if len(g.loopStack) == 0 {
+		g.errorf(node.Pos(), "continue-outside-loop", "continue outside of loop")
+		return
+	}
+	loop := g.loopStack[len(g.loopStack)-1]
+	g.parentBlock.Br(loop.latch)
+}
+
 func (g *Irgen) returnSmt(node *ast.ReturnStatement) {
 	exp := g.expression(node.Result)
 	g.parentBlock.Ret(exp)
@@ -141,18 +335,29 @@ func (g *Irgen) declareSmt(node *ast.DeclareStatement) {
 	g.parentBlock.Store(alloc, exp)
 
 	g.scope.AddVar(name, alloc)
+	if structName, ok := structTypeName(decl.Type.Base()); ok {
+		g.scope.AddVarType(name, structName)
+	}
 }
 
 func (g *Irgen) assignmentSmt(node *ast.AssignmentStatement) {
-	name := node.Left.(*ast.IdentExpression).Value.Value()
 	exp := g.expression(node.Right)
 
-	alloc, ok := g.scope.GetVar(name)
-	if !ok {
-		log.Fatalf("%q was not in scope", name)
+	switch left := node.Left.(type) {
+	case *ast.IdentExpression:
+		name := left.Value.Value()
+		alloc, ok := g.scope.GetVar(name)
+		if !ok {
+			g.errorf(left.Pos(), "undefined", "undefined: %s", name)
+			return
+		}
+		g.parentBlock.Store(alloc, exp)
+		g.scope.AddVar(name, alloc)
+	case *ast.SelectorExpression:
+		g.parentBlock.Store(g.selectorPtr(left), exp)
+	case *ast.IndexExpression:
+		g.parentBlock.Store(g.indexPtr(left), exp)
 	}
-	g.parentBlock.Store(alloc, exp)
-	g.scope.AddVar(name, alloc)
 }
 
 func (g *Irgen) expression(node ast.Expression) gooryvalues.Value {
@@ -167,71 +372,323 @@ func (g *Irgen) expression(node ast.Expression) gooryvalues.Value {
 		return g.identExp(node)
 	case *ast.CallExpression:
 		return g.callExp(node)
+	case *ast.CompositeLiteral:
+		return g.compositeExp(node)
+	case *ast.SelectorExpression:
+		return g.selectorExp(node)
+	case *ast.IndexExpression:
+		return g.indexExp(node)
 	default:
-		panic(fmt.Sprintf("Unknown expression node: %s", pp.Sprint(node)))
+		g.errorf(node.Pos(), "unknown-expression", "unknown expression node: %s", reflect.TypeOf(node))
+		return g.poison()
 	}
 }
 
 func (g *Irgen) callExp(node *ast.CallExpression) gooryvalues.Value {
 	// TODO: handle lambda's (i.e. functions that are not called by name)
-	funcName := node.Function.(*ast.IdentExpression).Value.Value()
+	switch fn := node.Function.(type) {
+	case *ast.SelectorExpression:
+		return g.methodCallExp(node, fn)
+	case *ast.IdentExpression:
+		switch fn.Value.Value() {
+		case "len":
+			return g.sliceFieldExp(node, sliceLenField)
+		case "cap":
+			return g.sliceFieldExp(node, sliceCapField)
+		case "make":
+			return g.makeExp(node)
+		}
+
+		funcName := fn.Value.Value()
+		function, _ := g.scope.GetFunction(funcName)
+
+		args := make([]gooryvalues.Value, len(node.Arguments.Elements))
+		for i, element := range node.Arguments.Elements {
+			args[i] = g.expression(element)
+		}
+
+		return g.parentBlock.Call(function, args...)
+	default:
+		g.errorf(node.Pos(), "unknown-call-target", "cannot call %s", reflect.TypeOf(node.Function))
+		return g.poison()
+	}
+}
+
+// methodCallExp lowers `x.method(args)` by resolving method against the
+// receiver's struct type and passing x as the first argument, mirroring how
+// the method's receiver was desugared when the struct's function was built.
+func (g *Irgen) methodCallExp(node *ast.CallExpression, selector *ast.SelectorExpression) gooryvalues.Value {
+	receiverName := selector.Target.(*ast.IdentExpression).Value.Value()
+	methodName := selector.Field.Value.Value()
+
+	structName, ok := g.scope.GetVarType(receiverName)
+	if !ok {
+		g.errorf(selector.Pos(), "unknown-type", "%q has no known struct type", receiverName)
+		return g.poison()
+	}
 
-	log.Printf("Function name: %q", funcName)
-	function, _ := g.scope.GetFunction(funcName)
+	function, ok := g.scope.GetMethod(structName, methodName)
+	if !ok {
+		g.errorf(selector.Pos(), "unknown-method", "%s has no method %q", structName, methodName)
+		return g.poison()
+	}
+
+	receiver, ok := g.scope.GetVar(receiverName)
+	if !ok {
+		g.errorf(selector.Pos(), "undefined", "undefined: %s", receiverName)
+		return g.poison()
+	}
 
-	args := make([]gooryvalues.Value, len(node.Arguments.Elements))
+	args := make([]gooryvalues.Value, len(node.Arguments.Elements)+1)
+	args[0] = g.parentBlock.Load(receiver)
 	for i, element := range node.Arguments.Elements {
-		args[i] = g.expression(element)
+		args[i+1] = g.expression(element)
 	}
 
 	return g.parentBlock.Call(function, args...)
 }
 
+// compositeExp lowers a composite literal, dispatching on whether it
+// constructs a named struct or a fixed-size array.
+func (g *Irgen) compositeExp(node *ast.CompositeLiteral) gooryvalues.Value {
+	switch t := node.Type.(type) {
+	case *ast.ArrayType:
+		return g.arrayCompositeExp(node, t)
+	case *ast.IdentExpression:
+		return g.structCompositeExp(node, t)
+	default:
+		g.errorf(node.Pos(), "unknown-composite-type", "unknown composite literal type: %s", reflect.TypeOf(node.Type))
+		return g.poison()
+	}
+}
+
+// structCompositeExp allocates a struct instance and stores each field's
+// value into it, then loads the completed value.
+func (g *Irgen) structCompositeExp(node *ast.CompositeLiteral, ident *ast.IdentExpression) gooryvalues.Value {
+	name := ident.Value.Value()
+
+	st, ok := g.scope.GetType(name)
+	if !ok {
+		g.errorf(ident.Pos(), "unknown-type", "%q is not a known type", name)
+		return g.poison()
+	}
+
+	alloc := g.parentBlock.Alloca(st.llvm)
+	for i, field := range st.fields {
+		value, ok := node.Fields[field]
+		if !ok {
+			continue
+		}
+		ptr := g.parentBlock.Gep(alloc, goory.ConstantInt(0), goory.ConstantInt(i))
+		g.parentBlock.Store(ptr, g.expression(value))
+	}
+
+	return g.parentBlock.Load(alloc)
+}
+
+// arrayCompositeExp lowers `[N]T{...}` to a chain of insertvalue
+// instructions building up the array constant element by element.
+func (g *Irgen) arrayCompositeExp(node *ast.CompositeLiteral, arrType *ast.ArrayType) gooryvalues.Value {
+	value := goory.Undef(arrType.Llvm())
+
+	for i, element := range node.Elements {
+		value = g.parentBlock.InsertValue(value, g.expression(element), i)
+	}
+
+	return value
+}
+
+// indexExp lowers a read of `s[i]` to a load of the pointer indexPtr
+// resolves.
+func (g *Irgen) indexExp(node *ast.IndexExpression) gooryvalues.Value {
+	return g.parentBlock.Load(g.indexPtr(node))
+}
+
+// indexPtr resolves `s[i]` to a pointer to the indexed element. Fixed-size
+// arrays are indexed directly; slices are bounds-checked against their
+// runtime length first, trapping into panic_index_out_of_range on failure.
+func (g *Irgen) indexPtr(node *ast.IndexExpression) gooryvalues.Value {
+	target := node.Target.(*ast.IdentExpression)
+	name := target.Value.Value()
+	alloc, ok := g.scope.GetVar(name)
+	if !ok {
+		g.errorf(target.Pos(), "undefined", "undefined: %s", name)
+		return g.poison()
+	}
+
+	index := g.expression(node.Index)
+
+	if _, isArray := g.info.Types[node.Target].(interface{ Len() int }); isArray {
+		return g.parentBlock.Gep(alloc, goory.ConstantInt(0), index)
+	}
+
+	lengthPtr := g.parentBlock.Gep(alloc, goory.ConstantInt(0), goory.ConstantInt(sliceLenField))
+	length := g.parentBlock.Load(lengthPtr)
+	nonNegative := g.parentBlock.Icmp(goory.IntSge, index, goory.ConstantInt(0))
+	lessThanLen := g.parentBlock.Icmp(goory.IntSlt, index, length)
+	inBounds := g.parentBlock.And(nonNegative, lessThanLen)
+
+	trap := g.parentBlock.Function().AddBlock()
+	inRange := g.parentBlock.Function().AddBlock()
+	g.parentBlock.CondBr(inBounds, inRange, trap)
+
+	g.parentBlock = trap
+	panicFn, _ := g.scope.GetFunction("panic_index_out_of_range")
+	g.parentBlock.Call(panicFn)
+	g.parentBlock.Unreachable()
+
+	g.parentBlock = inRange
+	dataPtr := g.parentBlock.Gep(alloc, goory.ConstantInt(0), goory.ConstantInt(sliceDataField))
+	data := g.parentBlock.Load(dataPtr)
+	return g.parentBlock.Gep(data, index)
+}
+
+// sliceFieldExp lowers `len(s)`/`cap(s)` to a load of the slice's len/cap
+// field.
+func (g *Irgen) sliceFieldExp(node *ast.CallExpression, field int) gooryvalues.Value {
+	target := node.Arguments.Elements[0].(*ast.IdentExpression)
+	name := target.Value.Value()
+	alloc, ok := g.scope.GetVar(name)
+	if !ok {
+		g.errorf(target.Pos(), "undefined", "undefined: %s", name)
+		return g.poison()
+	}
+
+	return g.parentBlock.Load(g.parentBlock.Gep(alloc, goory.ConstantInt(0), goory.ConstantInt(field)))
+}
+
+// makeExp lowers `make([]T, n)` to a runtime allocation sized by n times T's
+// element size, filling in the resulting slice's ptr/len/cap fields.
+func (g *Irgen) makeExp(node *ast.CallExpression) gooryvalues.Value {
+	sliceType := node.Arguments.Elements[0].(*ast.TypeExpression).Type
+	length := g.expression(node.Arguments.Elements[1])
+
+	elem, ok := sliceType.(interface{ Elem() types.Type })
+	if !ok {
+		g.errorf(node.Pos(), "bad-make", "make requires a slice type")
+		return g.poison()
+	}
+	elemLlvm := elem.Elem().Llvm()
+
+	size := g.parentBlock.Mul(length, g.elementSize(elemLlvm))
+	allocFn, _ := g.scope.GetFunction("runtime_alloc")
+	data := g.parentBlock.Call(allocFn, size)
+	dataPtr := g.parentBlock.Cast(data, goory.PointerType(elemLlvm))
+
+	slice := g.parentBlock.Alloca(sliceType.Llvm())
+	g.parentBlock.Store(g.parentBlock.Gep(slice, goory.ConstantInt(0), goory.ConstantInt(sliceDataField)), dataPtr)
+	g.parentBlock.Store(g.parentBlock.Gep(slice, goory.ConstantInt(0), goory.ConstantInt(sliceLenField)), length)
+	g.parentBlock.Store(g.parentBlock.Gep(slice, goory.ConstantInt(0), goory.ConstantInt(sliceCapField)), length)
+
+	return g.parentBlock.Load(slice)
+}
+
+// elementSize computes the byte size of t with the usual null-pointer GEP
+// trick: indexing one element past a null pointer and reinterpreting the
+// offset as an integer.
+func (g *Irgen) elementSize(t goory.Type) gooryvalues.Value {
+	null := goory.Constant(goory.PointerType(t), 0)
+	offset := g.parentBlock.Gep(null, goory.ConstantInt(1))
+	return g.parentBlock.Cast(offset, goory.IntType(64))
+}
+
+// selectorExp lowers a struct field read `x.field` to a GEP followed by a
+// load.
+func (g *Irgen) selectorExp(node *ast.SelectorExpression) gooryvalues.Value {
+	return g.parentBlock.Load(g.selectorPtr(node))
+}
+
+// selectorPtr resolves `x.field` to a pointer to that field, shared by
+// selector reads and assignment targets.
+func (g *Irgen) selectorPtr(node *ast.SelectorExpression) gooryvalues.Value {
+	target := node.Target.(*ast.IdentExpression)
+	name := target.Value.Value()
+	field := node.Field.Value.Value()
+
+	alloc, ok := g.scope.GetVar(name)
+	if !ok {
+		g.errorf(target.Pos(), "undefined", "undefined: %s", name)
+		return g.poison()
+	}
+
+	structName, ok := g.scope.GetVarType(name)
+	if !ok {
+		g.errorf(node.Pos(), "unknown-type", "%q has no known struct type", name)
+		return g.poison()
+	}
+
+	st, ok := g.scope.GetType(structName)
+	if !ok {
+		g.errorf(node.Pos(), "unknown-type", "%q is not a known type", structName)
+		return g.poison()
+	}
+
+	index := -1
+	for i, f := range st.fields {
+		if f == field {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		g.errorf(node.Pos(), "unknown-field", "%s has no field %q", structName, field)
+		return g.poison()
+	}
+
+	return g.parentBlock.Gep(alloc, goory.ConstantInt(0), goory.ConstantInt(index))
+}
+
 func (g *Irgen) identExp(node *ast.IdentExpression) gooryvalues.Value {
 	ident := node.Value.Value()
 
-	// TODO: do this with a map
-	if ident == "true" {
-		return goory.Constant(goory.BoolType(), true)
-	} else if ident == "false" {
-		return goory.Constant(goory.BoolType(), false)
+	if value, ok := sema.UniversalBool(ident); ok {
+		return goory.Constant(goory.BoolType(), value)
 	}
 
 	item, ok := g.scope.GetVar(ident)
 	if !ok {
-		log.Fatalf("%q was not is scope", ident)
+		g.errorf(node.Pos(), "undefined", "undefined: %s", ident)
+		return g.poison()
 	}
 
 	return g.parentBlock.Load(item)
 }
 
 func (g *Irgen) literalExp(node *ast.LiteralExpression) gooryvalues.Value {
+	litType := g.info.Types[node]
+
 	switch node.Value.Type() {
 	case lexer.INT:
 		value, _ := strconv.Atoi(node.Value.Value())
-		return goory.Constant(types.IntType(0).Llvm(), value)
+		return goory.Constant(litType.Llvm(), value)
 	case lexer.FLOAT:
 		value, _ := strconv.ParseFloat(node.Value.Value(), 64)
-		return goory.Constant(types.FloatType(0).Llvm(), value)
+		return goory.Constant(litType.Llvm(), value)
 	default:
-		panic("Unknown literal type")
+		g.errorf(node.Pos(), "unknown-literal", "unknown literal type %v", node.Value.Type())
+		return g.poison()
 	}
 }
 
 func (g *Irgen) castExp(node *ast.CastExpression) gooryvalues.Value {
 	exp := g.expression(node.Expression)
-	log.Printf("Casting to: %s", node.Type.Llvm())
-	log.Printf("%s", pp.Sprint(exp.Type()))
 	return g.parentBlock.Cast(exp, node.Type.Llvm())
 }
 
 func (g *Irgen) binaryExp(node *ast.BinaryExpression) gooryvalues.Value {
+	switch node.Operator.Type() {
+	case lexer.LAND:
+		return g.shortCircuitExp(node, false)
+	case lexer.LOR:
+		return g.shortCircuitExp(node, true)
+	}
+
 	left := g.expression(node.Left)
 	right := g.expression(node.Right)
 
-	log.Printf("Is fp: %t", node.IsFp)
+	isFp := isFloatType(g.info.Types[node.Left])
 
-	if node.IsFp {
+	if isFp {
 		switch node.Operator.Type() {
 		case lexer.ADD:
 			return g.parentBlock.Fadd(left, right)
@@ -273,5 +730,45 @@ func (g *Irgen) binaryExp(node *ast.BinaryExpression) gooryvalues.Value {
 		}
 	}
 
-	panic("Unhandled binary operator")
+	g.errorf(node.Pos(), "unknown-operator", "unhandled binary operator %s", node.Operator.Type())
+	return g.poison()
+}
+
+// shortCircuitExp lowers `a && b` (or, with or=true, `a || b`) into a rhs block
+// that only evaluates b when a hasn't already decided the result, and a
+// merge block with a phi choosing between the short-circuit constant and
+// b's value, so the right operand is never evaluated unconditionally.
+func (g *Irgen) shortCircuitExp(node *ast.BinaryExpression, or bool) gooryvalues.Value {
+	function := g.parentBlock.Function()
+	rhs := function.AddBlock()
+	merge := function.AddBlock()
+
+	left := g.expression(node.Left)
+	lhsBlock := g.parentBlock
+	if or {
+		lhsBlock.CondBr(left, merge, rhs)
+	} else {
+		lhsBlock.CondBr(left, rhs, merge)
+	}
+
+	g.parentBlock = rhs
+	right := g.expression(node.Right)
+	rhsBlock := g.parentBlock
+	if !rhsBlock.Terminated() {
+		rhsBlock.Br(merge)
+	}
+
+	g.parentBlock = merge
+	shortCircuit := goory.Constant(goory.BoolType(), or)
+	return merge.Phi(goory.BoolType(), map[*goory.Block]gooryvalues.Value{
+		lhsBlock: shortCircuit,
+		rhsBlock: right,
+	})
+}
+
+// isFloatType reports whether t is the floating-point type, so binaryExp
+// can pick its float-vs-int lowering from the type sema resolved instead of
+// a parser-computed flag.
+func isFloatType(t types.Type) bool {
+	return reflect.TypeOf(t) == reflect.TypeOf(types.FloatType(0))
 }
@@ -0,0 +1,124 @@
+package irgen
+
+import (
+	"github.com/bongo227/goory"
+	gooryvalues "github.com/bongo227/goory/value"
+)
+
+// Scope resolves names to the LLVM values and struct metadata Irgen needs
+// while lowering: variables (allocas), functions, methods (keyed by their
+// receiver's struct name), the struct name a variable was declared with,
+// and the struct types themselves. It nests the way Furlang's own lexical
+// scoping works, walking out to enclosing scopes on a miss.
+type Scope struct {
+	parent    *Scope
+	vars      map[string]gooryvalues.Value
+	varTypes  map[string]string
+	functions map[string]*goory.Function
+	methods   map[string]map[string]*goory.Function
+	types     map[string]*structType
+}
+
+// NewScope returns an empty top-level Scope.
+func NewScope() *Scope {
+	return &Scope{
+		vars:      make(map[string]gooryvalues.Value),
+		varTypes:  make(map[string]string),
+		functions: make(map[string]*goory.Function),
+		methods:   make(map[string]map[string]*goory.Function),
+		types:     make(map[string]*structType),
+	}
+}
+
+// Push returns a new Scope nested inside s, so names declared in it shadow
+// s's without disturbing it.
+func (s *Scope) Push() *Scope {
+	child := NewScope()
+	child.parent = s
+	return child
+}
+
+// AddVar records the alloca backing the variable name.
+func (s *Scope) AddVar(name string, value gooryvalues.Value) {
+	s.vars[name] = value
+}
+
+// GetVar resolves name to the alloca it was declared with, walking out to
+// enclosing scopes on a miss.
+func (s *Scope) GetVar(name string) (gooryvalues.Value, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if v, ok := cur.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// AddVarType records the named struct type the variable name was declared
+// with, so a later selector or method call can look up its fields/methods.
+func (s *Scope) AddVarType(name string, structName string) {
+	s.varTypes[name] = structName
+}
+
+// GetVarType resolves name to the struct type name it was declared with.
+func (s *Scope) GetVarType(name string) (string, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if t, ok := cur.varTypes[name]; ok {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// AddFunction records the LLVM function fn under name.
+func (s *Scope) AddFunction(name string, fn *goory.Function) {
+	s.functions[name] = fn
+}
+
+// GetFunction resolves name to the LLVM function it was declared with.
+func (s *Scope) GetFunction(name string) (*goory.Function, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if fn, ok := cur.functions[name]; ok {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// AddMethod records fn as methodName on the struct type structName.
+func (s *Scope) AddMethod(structName, methodName string, fn *goory.Function) {
+	methods, ok := s.methods[structName]
+	if !ok {
+		methods = make(map[string]*goory.Function)
+		s.methods[structName] = methods
+	}
+	methods[methodName] = fn
+}
+
+// GetMethod resolves methodName on the struct type structName to the LLVM
+// function it was declared with.
+func (s *Scope) GetMethod(structName, methodName string) (*goory.Function, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if methods, ok := cur.methods[structName]; ok {
+			if fn, ok := methods[methodName]; ok {
+				return fn, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// AddType records the struct type metadata st under name.
+func (s *Scope) AddType(name string, st *structType) {
+	s.types[name] = st
+}
+
+// GetType resolves name to the struct type metadata it was declared with.
+func (s *Scope) GetType(name string) (*structType, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if st, ok := cur.types[name]; ok {
+			return st, true
+		}
+	}
+	return nil, false
+}
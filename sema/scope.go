@@ -0,0 +1,78 @@
+package sema
+
+import (
+	"github.com/bongo227/Furlang/ast"
+	"github.com/bongo227/Furlang/types"
+)
+
+type declKind int
+
+const (
+	builtinDecl declKind = iota
+	varDecl
+	funcDecl
+)
+
+// decl is what an identifier resolves to: a builtin (true/false/nil), a
+// package-level function, or a typed value (a variable or a parameter).
+type decl struct {
+	kind declKind
+	typ  types.Type
+	fn   *ast.FunctionDeclaration
+}
+
+// scope resolves identifiers to declarations, walking out to enclosing
+// scopes the way Furlang's own lexical scoping works.
+type scope struct {
+	parent *scope
+	decls  map[string]*decl
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, decls: make(map[string]*decl)}
+}
+
+// universe is the outermost scope, pre-populated with the identifiers that
+// need no declaration of their own: true, false and nil.
+func universe() *scope {
+	u := newScope(nil)
+	u.decls["true"] = &decl{kind: builtinDecl, typ: types.BoolType()}
+	u.decls["false"] = &decl{kind: builtinDecl, typ: types.BoolType()}
+	u.decls["nil"] = &decl{kind: builtinDecl}
+	return u
+}
+
+func (s *scope) push() *scope {
+	return newScope(s)
+}
+
+func (s *scope) declareVar(name string, typ types.Type) {
+	s.decls[name] = &decl{kind: varDecl, typ: typ}
+}
+
+func (s *scope) declareFunc(name string, node *ast.FunctionDeclaration) {
+	s.decls[name] = &decl{kind: funcDecl, typ: node.Return, fn: node}
+}
+
+func (s *scope) lookup(name string) (*decl, bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if d, ok := cur.decls[name]; ok {
+			return d, true
+		}
+	}
+	return nil, false
+}
+
+// UniversalBool reports whether name is one of the universally declared
+// boolean identifiers, and its value if so. Irgen uses this to lower
+// identifier expressions without string-matching against literal names.
+func UniversalBool(name string) (value bool, ok bool) {
+	switch name {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	default:
+		return false, false
+	}
+}
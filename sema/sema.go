@@ -0,0 +1,426 @@
+// Package sema implements semantic analysis for Furlang: resolving
+// identifiers to the declarations they refer to, inferring the type of
+// every expression, and checking that implicit conversions between binary
+// operands, assignments, returns and call arguments are legal. Irgen
+// consumes the result instead of recomputing any of this itself.
+package sema
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/bongo227/Furlang/ast"
+	"github.com/bongo227/Furlang/diag"
+	"github.com/bongo227/Furlang/lexer"
+	"github.com/bongo227/Furlang/types"
+)
+
+// Info holds the result of semantic analysis: every ast.Expression resolved
+// to its inferred type.
+type Info struct {
+	Types map[ast.Expression]types.Type
+}
+
+func newInfo() *Info {
+	return &Info{Types: make(map[ast.Expression]types.Type)}
+}
+
+// checker walks an *ast.Ast resolving identifiers and inferring types. It
+// never aborts on the first problem; every diagnostic found along the way
+// is written to diags instead, so a caller can report all of them at once.
+type checker struct {
+	info    *Info
+	scope   *scope
+	diags   *diag.Reporter
+	current *ast.FunctionDeclaration
+	structs map[string]*ast.StructDeclaration
+}
+
+// Check resolves every identifier in tree to its declaration and annotates
+// every expression with its inferred type, checking that binary operators,
+// assignments, returns and call arguments only mix compatible types. Any
+// problem it finds is written to diags rather than returned, so the
+// parser, sema and irgen passes can all report through the one Reporter.
+func Check(tree *ast.Ast, diags *diag.Reporter) *Info {
+	c := &checker{
+		info:    newInfo(),
+		scope:   universe(),
+		diags:   diags,
+		structs: make(map[string]*ast.StructDeclaration),
+	}
+
+	for _, s := range tree.Structs {
+		c.structs[s.Name.Value.Value()] = s
+	}
+
+	for _, f := range tree.Functions {
+		c.scope.declareFunc(f.Name.Value.Value(), f)
+	}
+
+	for _, f := range tree.Functions {
+		c.checkFunction(f)
+	}
+
+	return c.info
+}
+
+func (c *checker) errorf(pos lexer.Position, code, format string, args ...interface{}) {
+	c.diags.Errorf(pos, code, format, args...)
+}
+
+func (c *checker) checkFunction(node *ast.FunctionDeclaration) {
+	c.current = node
+	parent := c.scope
+	c.scope = c.scope.push()
+
+	if node.Receiver != nil {
+		c.scope.declareVar(node.Receiver.Name.Value.Value(), node.Receiver.Type)
+	}
+	for _, arg := range node.Arguments {
+		c.scope.declareVar(arg.Name.Value.Value(), arg.Type)
+	}
+
+	c.checkBlock(node.Body)
+	c.scope = parent
+}
+
+func (c *checker) checkBlock(node *ast.BlockStatement) {
+	parent := c.scope
+	c.scope = c.scope.push()
+	for _, smt := range node.Statements {
+		c.checkStatement(smt)
+	}
+	c.scope = parent
+}
+
+func (c *checker) checkStatement(node ast.Statement) {
+	switch node := node.(type) {
+	case *ast.IfStatment:
+		if node.Condition != nil {
+			c.checkExpression(node.Condition)
+		}
+		c.checkBlock(node.Body)
+		if node.Else != nil {
+			c.checkStatement(node.Else)
+		}
+	case *ast.ForStatement:
+		parent := c.scope
+		c.scope = c.scope.push()
+		if node.Init != nil {
+			c.checkStatement(node.Init)
+		}
+		if node.Condition != nil {
+			c.checkExpression(node.Condition)
+		}
+		if node.Post != nil {
+			c.checkStatement(node.Post)
+		}
+		c.checkBlock(node.Body)
+		c.scope = parent
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		// Nothing to resolve.
+	case *ast.ReturnStatement:
+		t := c.checkExpression(node.Result)
+		if c.current != nil && !convertibleTo(t, c.current.Return) {
+			c.errorf(node.Pos(), "bad-return", "cannot return %v value as %v", t, c.current.Return)
+		}
+	case *ast.DeclareStatement:
+		decl := node.Statement.(*ast.VaribleDeclaration)
+		t := c.checkExpression(decl.Value)
+		if !convertibleTo(t, decl.Type) {
+			c.errorf(node.Pos(), "bad-init", "cannot initialise %q of type %v with %v value",
+				decl.Name.Value.Value(), decl.Type, t)
+		}
+		c.scope.declareVar(decl.Name.Value.Value(), decl.Type)
+	case *ast.AssignmentStatement:
+		lt := c.checkExpression(node.Left)
+		rt := c.checkExpression(node.Right)
+		if !convertibleTo(rt, lt) {
+			c.errorf(node.Pos(), "bad-assign", "cannot assign %v value to %s of type %v",
+				rt, assignTarget(node.Left), lt)
+		}
+	}
+}
+
+// assignTarget renders an assignment's left-hand side for diagnostics,
+// covering the shapes assignmentSmt accepts: a bare identifier, a struct
+// field selector, or an indexed element.
+func assignTarget(node ast.Expression) string {
+	switch left := node.(type) {
+	case *ast.IdentExpression:
+		return fmt.Sprintf("%q", left.Value.Value())
+	case *ast.SelectorExpression:
+		if ident, ok := left.Target.(*ast.IdentExpression); ok {
+			return fmt.Sprintf("%q", ident.Value.Value()+"."+left.Field.Value.Value())
+		}
+		return fmt.Sprintf("field %q", left.Field.Value.Value())
+	case *ast.IndexExpression:
+		if ident, ok := left.Target.(*ast.IdentExpression); ok {
+			return fmt.Sprintf("%q", ident.Value.Value()+"[...]")
+		}
+		return "index expression"
+	default:
+		return "expression"
+	}
+}
+
+func (c *checker) checkExpression(node ast.Expression) types.Type {
+	var t types.Type
+
+	switch node := node.(type) {
+	case *ast.BinaryExpression:
+		t = c.checkBinary(node)
+	case *ast.CastExpression:
+		c.checkExpression(node.Expression)
+		t = node.Type
+	case *ast.LiteralExpression:
+		t = c.checkLiteral(node)
+	case *ast.IdentExpression:
+		t = c.checkIdent(node)
+	case *ast.CallExpression:
+		t = c.checkCall(node)
+	case *ast.IndexExpression:
+		t = c.checkIndex(node)
+	case *ast.SelectorExpression:
+		t = c.checkSelector(node)
+	case *ast.CompositeLiteral:
+		t = c.checkComposite(node)
+	default:
+		c.errorf(node.Pos(), "unknown-expression", "unknown expression node: %s", reflect.TypeOf(node))
+	}
+
+	c.info.Types[node] = t
+	return t
+}
+
+func (c *checker) checkBinary(node *ast.BinaryExpression) types.Type {
+	lt := c.checkExpression(node.Left)
+	rt := c.checkExpression(node.Right)
+
+	switch node.Operator.Type() {
+	case lexer.EQL, lexer.NEQ, lexer.LSS, lexer.GTR, lexer.LAND, lexer.LOR:
+		if !sameType(lt, rt) && !convertibleTo(lt, rt) && !convertibleTo(rt, lt) {
+			c.errorf(node.Pos(), "mismatched-types", "mismatched types %v and %v", lt, rt)
+		}
+		return types.BoolType()
+	}
+
+	switch {
+	case sameType(lt, rt):
+		return lt
+	case convertibleTo(lt, rt):
+		return rt
+	case convertibleTo(rt, lt):
+		return lt
+	default:
+		c.errorf(node.Pos(), "mismatched-types", "mismatched types %v and %v", lt, rt)
+		return lt
+	}
+}
+
+func (c *checker) checkLiteral(node *ast.LiteralExpression) types.Type {
+	switch node.Value.Type() {
+	case lexer.INT:
+		return types.IntType(0)
+	case lexer.FLOAT:
+		return types.FloatType(0)
+	default:
+		c.errorf(node.Pos(), "unknown-literal", "unknown literal type %v", node.Value.Type())
+		return nil
+	}
+}
+
+func (c *checker) checkIdent(node *ast.IdentExpression) types.Type {
+	name := node.Value.Value()
+
+	d, ok := c.scope.lookup(name)
+	if !ok {
+		c.errorf(node.Pos(), "undefined", "undefined: %s", name)
+		return nil
+	}
+
+	return d.typ
+}
+
+// checkCall resolves the return type of a call. len/cap/make are builtins
+// rather than declared functions, so they're recognised by name before
+// falling back to looking the callee up as an ordinary function.
+func (c *checker) checkCall(node *ast.CallExpression) types.Type {
+	ident, ok := node.Function.(*ast.IdentExpression)
+	if !ok {
+		c.errorf(node.Pos(), "bad-call", "cannot call a non-identifier expression")
+		return nil
+	}
+
+	name := ident.Value.Value()
+	if t, ok := c.checkBuiltinCall(node, name); ok {
+		return t
+	}
+
+	d, ok := c.scope.lookup(name)
+	if !ok || d.kind != funcDecl {
+		c.errorf(node.Pos(), "not-a-function", "%q is not a function", name)
+		return nil
+	}
+
+	if len(node.Arguments.Elements) != len(d.fn.Arguments) {
+		c.errorf(node.Pos(), "bad-arg-count", "%q expects %d arguments, got %d",
+			name, len(d.fn.Arguments), len(node.Arguments.Elements))
+	}
+
+	for i, element := range node.Arguments.Elements {
+		at := c.checkExpression(element)
+		if i < len(d.fn.Arguments) && !convertibleTo(at, d.fn.Arguments[i].Type) {
+			c.errorf(node.Pos(), "bad-arg", "argument %d to %q: cannot use %v value as %v",
+				i, name, at, d.fn.Arguments[i].Type)
+		}
+	}
+
+	return d.fn.Return
+}
+
+// checkBuiltinCall recognises len(s), cap(s) and make(T, n), which aren't
+// declared functions, so checkCall can dispatch them before falling back to
+// an ordinary function lookup. ok is false if name isn't a builtin.
+func (c *checker) checkBuiltinCall(node *ast.CallExpression, name string) (t types.Type, ok bool) {
+	switch name {
+	case "len", "cap":
+		for _, element := range node.Arguments.Elements {
+			c.checkExpression(element)
+		}
+		return types.IntType(0), true
+	case "make":
+		te, ok := firstElement(node)
+		if !ok {
+			c.errorf(node.Pos(), "bad-call", "make expects a type argument")
+			return nil, true
+		}
+		for _, element := range node.Arguments.Elements[1:] {
+			c.checkExpression(element)
+		}
+		return te.Type, true
+	default:
+		return nil, false
+	}
+}
+
+// firstElement returns node's first argument as a type expression, the
+// shape make(T, n) requires for its type argument.
+func firstElement(node *ast.CallExpression) (*ast.TypeExpression, bool) {
+	if len(node.Arguments.Elements) == 0 {
+		return nil, false
+	}
+	te, ok := node.Arguments.Elements[0].(*ast.TypeExpression)
+	return te, ok
+}
+
+// checkIndex resolves `s[i]` to the element type of s, reporting a
+// diagnostic if i isn't an integer or s can't be indexed.
+func (c *checker) checkIndex(node *ast.IndexExpression) types.Type {
+	targetType := c.checkExpression(node.Target)
+	indexType := c.checkExpression(node.Index)
+
+	if !convertibleTo(indexType, types.IntType(0)) {
+		c.errorf(node.Pos(), "bad-index", "cannot index with %v value", indexType)
+	}
+
+	elem, ok := targetType.(interface{ Elem() types.Type })
+	if !ok {
+		c.errorf(node.Pos(), "not-indexable", "cannot index %v value", targetType)
+		return targetType
+	}
+	return elem.Elem()
+}
+
+// checkSelector resolves `x.field` to field's declared type, looking up x's
+// struct declaration by the name its type reports.
+func (c *checker) checkSelector(node *ast.SelectorExpression) types.Type {
+	targetType := c.checkExpression(node.Target)
+
+	named, ok := targetType.(interface{ Name() string })
+	if !ok {
+		c.errorf(node.Pos(), "unknown-type", "%v has no fields", targetType)
+		return nil
+	}
+
+	decl, ok := c.structs[named.Name()]
+	if !ok {
+		c.errorf(node.Pos(), "unknown-type", "%q is not a known struct type", named.Name())
+		return nil
+	}
+
+	fieldName := node.Field.Value.Value()
+	for _, field := range decl.Fields {
+		if field.Name.Value.Value() == fieldName {
+			return field.Type
+		}
+	}
+
+	c.errorf(node.Pos(), "unknown-field", "%s has no field %q", named.Name(), fieldName)
+	return nil
+}
+
+// checkComposite resolves a composite literal's type, checking its elements
+// (array literals) or its fields against the struct declaration (named
+// literals) so every sub-expression gets an entry in info.Types too.
+func (c *checker) checkComposite(node *ast.CompositeLiteral) types.Type {
+	switch t := node.Type.(type) {
+	case *ast.ArrayType:
+		for _, element := range node.Elements {
+			c.checkExpression(element)
+		}
+		return t
+	case *ast.IdentExpression:
+		name := t.Value.Value()
+		decl, ok := c.structs[name]
+		if !ok {
+			c.errorf(node.Pos(), "unknown-type", "%q is not a known type", name)
+			return nil
+		}
+
+		for fieldName, value := range node.Fields {
+			valueType := c.checkExpression(value)
+
+			found := false
+			for _, field := range decl.Fields {
+				if field.Name.Value.Value() != fieldName {
+					continue
+				}
+				found = true
+				if !convertibleTo(valueType, field.Type) {
+					c.errorf(node.Pos(), "bad-field", "cannot use %v value for field %q of type %v",
+						valueType, fieldName, field.Type)
+				}
+				break
+			}
+			if !found {
+				c.errorf(node.Pos(), "unknown-field", "%s has no field %q", name, fieldName)
+			}
+		}
+		return t
+	default:
+		c.errorf(node.Pos(), "unknown-composite-type", "unknown composite literal type: %s", reflect.TypeOf(node.Type))
+		return nil
+	}
+}
+
+func sameType(a, b types.Type) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+func isFloat(t types.Type) bool {
+	return reflect.TypeOf(t) == reflect.TypeOf(types.FloatType(0))
+}
+
+// convertibleTo reports whether a value of type from may be implicitly
+// converted to type to, e.g. an int operand combining with a float one.
+// Every other mismatch is a type error.
+func convertibleTo(from, to types.Type) bool {
+	if from == nil || to == nil {
+		return true // already reported
+	}
+	if sameType(from, to) {
+		return true
+	}
+	return reflect.TypeOf(from) == reflect.TypeOf(types.IntType(0)) && isFloat(to)
+}